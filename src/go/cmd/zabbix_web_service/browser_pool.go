@@ -0,0 +1,217 @@
+/*
+** Copyright (C) 2001-2024 Zabbix SIA
+**
+** This program is free software: you can redistribute it and/or modify it under the terms of
+** the GNU Affero General Public License as published by the Free Software Foundation, version 3.
+**
+** This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+** without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+** See the GNU Affero General Public License for more details.
+**
+** You should have received a copy of the GNU Affero General Public License along with this program.
+** If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/inspector"
+	"github.com/chromedp/chromedp"
+	"golang.zabbix.com/sdk/errs"
+	"golang.zabbix.com/sdk/log"
+)
+
+// backfillRetryDelay is how long to wait between attempts to replace a crashed worker.
+const backfillRetryDelay = 5 * time.Second
+
+// browserWorker owns one long-lived Chrome process.
+type browserWorker struct {
+	allocCtx    context.Context
+	allocCancel context.CancelFunc
+	ctx         context.Context
+	cancel      context.CancelFunc
+	crashed     atomic.Bool
+}
+
+// browserPool bounds the number of concurrent Chrome processes used to render reports.
+type browserPool struct {
+	ctx     context.Context
+	opts    []chromedp.ExecAllocatorOption
+	workers chan *browserWorker
+	mu      sync.Mutex
+	closed  bool
+}
+
+// defaultMaxConcurrentRequests is used when options.MaxConcurrentRequests is unset.
+const defaultMaxConcurrentRequests = 4
+
+// newReportBrowserPool builds the browser pool used by the report handler.
+func newReportBrowserPool(ctx context.Context) (*browserPool, error) {
+	size := options.MaxConcurrentRequests
+	if size < 1 {
+		size = defaultMaxConcurrentRequests
+	}
+
+	opts := chromedp.DefaultExecAllocatorOptions[:]
+
+	if options.IgnoreURLCertErrors == 1 {
+		opts = append(opts, chromedp.Flag("ignore-certificate-errors", "1"))
+	}
+
+	return newBrowserPool(ctx, size, opts)
+}
+
+// newBrowserPool starts size long-lived Chrome processes and returns a pool that hands out
+// isolated tab contexts for them. ctx controls the lifetime of every worker in the pool.
+func newBrowserPool(ctx context.Context, size int, opts []chromedp.ExecAllocatorOption) (*browserPool, error) {
+	if size < 1 {
+		return nil, errs.Errorf("pool size must be at least 1, got %d", size)
+	}
+
+	p := &browserPool{
+		ctx:     ctx,
+		opts:    opts,
+		workers: make(chan *browserWorker, size),
+	}
+
+	for i := 0; i < size; i++ {
+		worker, err := p.newWorker()
+		if err != nil {
+			p.shutdown()
+
+			return nil, errs.Wrapf(err, "cannot start browser worker %d/%d", i+1, size)
+		}
+
+		p.workers <- worker
+	}
+
+	return p, nil
+}
+
+// newWorker starts a fresh Chrome process and watches it for crashes.
+func (p *browserPool) newWorker() (*browserWorker, error) {
+	allocCtx, allocCancel := chromedp.NewExecAllocator(p.ctx, p.opts...)
+
+	browserCtx, cancel := chromedp.NewContext(allocCtx)
+	if err := chromedp.Run(browserCtx); err != nil {
+		cancel()
+		allocCancel()
+
+		return nil, err
+	}
+
+	worker := &browserWorker{
+		allocCtx:    allocCtx,
+		allocCancel: allocCancel,
+		ctx:         browserCtx,
+		cancel:      cancel,
+	}
+
+	chromedp.ListenBrowser(browserCtx, func(ev any) {
+		if _, ok := ev.(*inspector.EventTargetCrashed); ok {
+			worker.crashed.Store(true)
+		}
+	})
+
+	return worker, nil
+}
+
+// acquire blocks until a worker is available or ctx is done.
+func (p *browserPool) acquire(ctx context.Context) (*browserWorker, error) {
+	select {
+	case worker, ok := <-p.workers:
+		if !ok {
+			return nil, errs.New("browser pool is shut down")
+		}
+
+		if worker.ctx.Err() != nil || worker.crashed.Load() {
+			log.Warningf("browser worker is no longer healthy, restarting")
+
+			worker.cancel()
+			worker.allocCancel()
+
+			replacement, err := p.newWorker()
+			if err != nil {
+				log.Errf(
+					"cannot restart crashed browser worker, retrying in the background: %s", err.Error())
+
+				go p.backfillWorker()
+
+				return nil, errs.Wrap(err, "cannot restart crashed browser worker")
+			}
+
+			worker = replacement
+		}
+
+		return worker, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// backfillWorker retries starting a replacement browser process until it succeeds or the pool
+// is shut down.
+func (p *browserPool) backfillWorker() {
+	for attempt := 1; ; attempt++ {
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+
+		if closed {
+			return
+		}
+
+		worker, err := p.newWorker()
+		if err == nil {
+			p.release(worker)
+
+			return
+		}
+
+		log.Errf("backfill attempt %d to restart browser worker failed: %s", attempt, err.Error())
+
+		select {
+		case <-time.After(backfillRetryDelay):
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// release returns a worker to the pool, or drops it silently if the pool has been shut down.
+func (p *browserPool) release(worker *browserWorker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		worker.cancel()
+		worker.allocCancel()
+
+		return
+	}
+
+	p.workers <- worker
+}
+
+// shutdown cancels every worker in the pool and prevents further use of the pool.
+func (p *browserPool) shutdown() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return
+	}
+
+	p.closed = true
+	close(p.workers)
+
+	for worker := range p.workers {
+		worker.cancel()
+		worker.allocCancel()
+	}
+}
@@ -0,0 +1,126 @@
+/*
+** Copyright (C) 2001-2024 Zabbix SIA
+**
+** This program is free software: you can redistribute it and/or modify it under the terms of
+** the GNU Affero General Public License as published by the Free Software Foundation, version 3.
+**
+** This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+** without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+** See the GNU Affero General Public License for more details.
+**
+** You should have received a copy of the GNU Affero General Public License along with this program.
+** If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// newFakeWorker builds a browserWorker that tracks the pool without launching a real Chrome
+// process, so acquire()/release()/shutdown() bookkeeping can be tested without a browser.
+func newFakeWorker() (*browserWorker, *bool) {
+	cancelled := false
+
+	return &browserWorker{
+		allocCtx:    context.Background(),
+		allocCancel: func() {},
+		ctx:         context.Background(),
+		cancel:      func() { cancelled = true },
+	}, &cancelled
+}
+
+func TestNewBrowserPoolRejectsNonPositiveSize(t *testing.T) {
+	if _, err := newBrowserPool(context.Background(), 0, nil); err == nil {
+		t.Fatal("newBrowserPool(size=0) should fail")
+	}
+}
+
+func TestAcquireReturnsHealthyWorker(t *testing.T) {
+	worker, _ := newFakeWorker()
+
+	p := &browserPool{ctx: context.Background(), workers: make(chan *browserWorker, 1)}
+	p.workers <- worker
+
+	got, err := p.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire() error = %s", err.Error())
+	}
+
+	if got != worker {
+		t.Error("acquire() did not return the only worker in the pool")
+	}
+
+	if len(p.workers) != 0 {
+		t.Errorf("acquire() left %d workers in the pool, want 0", len(p.workers))
+	}
+}
+
+func TestAcquireRespectsCallerContext(t *testing.T) {
+	p := &browserPool{ctx: context.Background(), workers: make(chan *browserWorker, 1)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := p.acquire(ctx); err == nil {
+		t.Fatal("acquire() with an already-cancelled context should fail")
+	}
+}
+
+func TestReleasePutsWorkerBack(t *testing.T) {
+	worker, cancelled := newFakeWorker()
+
+	p := &browserPool{ctx: context.Background(), workers: make(chan *browserWorker, 1)}
+
+	p.release(worker)
+
+	if *cancelled {
+		t.Error("release() into an open pool should not cancel the worker")
+	}
+
+	select {
+	case got := <-p.workers:
+		if got != worker {
+			t.Error("release() did not return the same worker to the pool")
+		}
+	default:
+		t.Fatal("release() did not put the worker back on the pool's channel")
+	}
+}
+
+func TestReleaseAfterShutdownCancelsWorker(t *testing.T) {
+	worker, cancelled := newFakeWorker()
+
+	p := &browserPool{ctx: context.Background(), workers: make(chan *browserWorker, 1), closed: true}
+
+	p.release(worker)
+
+	if !*cancelled {
+		t.Error("release() into a shut down pool should cancel the worker instead of keeping it")
+	}
+
+	if len(p.workers) != 0 {
+		t.Error("release() into a shut down pool should not put the worker back on the channel")
+	}
+}
+
+func TestShutdownIsIdempotentAndClosesThePool(t *testing.T) {
+	worker, cancelled := newFakeWorker()
+
+	p := &browserPool{ctx: context.Background(), workers: make(chan *browserWorker, 1)}
+	p.workers <- worker
+
+	p.shutdown()
+
+	if !*cancelled {
+		t.Error("shutdown() should cancel every worker still in the pool")
+	}
+
+	p.shutdown() // must not panic on a second call (e.g. double-close of the channel)
+
+	if _, err := p.acquire(context.Background()); err == nil {
+		t.Fatal("acquire() on a shut down pool should fail")
+	}
+}
@@ -0,0 +1,149 @@
+/*
+** Copyright (C) 2001-2024 Zabbix SIA
+**
+** This program is free software: you can redistribute it and/or modify it under the terms of
+** the GNU Affero General Public License as published by the Free Software Foundation, version 3.
+**
+** This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+** without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+** See the GNU Affero General Public License for more details.
+**
+** You should have received a copy of the GNU Affero General Public License along with this program.
+** If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+func TestParseDurationMsParam(t *testing.T) {
+	const key = "settle_ms"
+
+	ms := func(v int64) time.Duration { return time.Duration(v) * time.Millisecond }
+
+	cases := []struct {
+		name    string
+		params  map[string]string
+		max     time.Duration
+		def     time.Duration
+		want    time.Duration
+		wantErr bool
+	}{
+		{"absent uses default", map[string]string{}, ms(30000), ms(7), ms(7), false},
+		{"empty uses default", map[string]string{key: ""}, ms(30000), ms(7), ms(7), false},
+		{"within bounds", map[string]string{key: "1500"}, ms(30000), 0, ms(1500), false},
+		{"at max", map[string]string{key: "30000"}, ms(30000), 0, ms(30000), false},
+		{"above max", map[string]string{key: "30001"}, ms(30000), 0, 0, true},
+		{"negative", map[string]string{key: "-1"}, ms(30000), 0, 0, true},
+		{"not an integer", map[string]string{key: "soon"}, ms(30000), 0, 0, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDurationMsParam(tt.params, key, tt.max, tt.def)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDurationMsParam() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseDurationMsParam() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWaitSelectors(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"single", ".widget-ready", []string{".widget-ready"}},
+		{"multiple trimmed", ".a, .b ,  .c", []string{".a", ".b", ".c"}},
+		{"drops empty entries", ".a,,  ,.b", []string{".a", ".b"}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseWaitSelectors(tt.raw)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseWaitSelectors(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseWaitSelectors(%q)[%d] = %q, want %q", tt.raw, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// dashboardReadyHTML mimics the frontend's readiness marker, plus a widget that lazy-loads
+// its content a short while after the marker appears (e.g. via an XHR the wrapper ready state
+// doesn't wait for).
+const dashboardReadyHTML = `<!DOCTYPE html>
+<html><body>
+<div class="wrapper is-ready"></div>
+<script>
+setTimeout(function() {
+	var el = document.createElement('div');
+	el.className = 'widget-ready';
+	document.body.appendChild(el);
+}, 200);
+</script>
+</body></html>`
+
+// newTestBrowserContext starts a headless Chrome and skips the test if none is available, since
+// this sandbox doesn't always have one.
+func newTestBrowserContext(t *testing.T) context.Context {
+	t.Helper()
+
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), chromedp.DefaultExecAllocatorOptions[:]...)
+	t.Cleanup(allocCancel)
+
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	t.Cleanup(cancel)
+
+	if err := chromedp.Run(ctx); err != nil {
+		t.Skipf("no headless chrome available: %s", err.Error())
+	}
+
+	return ctx
+}
+
+func TestWaitForDashboardReadyAndSelector(t *testing.T) {
+	ctx := newTestBrowserContext(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(dashboardReadyHTML))
+	}))
+	defer srv.Close()
+
+	if err := chromedp.Run(ctx, chromedp.Navigate(srv.URL)); err != nil {
+		t.Fatalf("navigate: %s", err.Error())
+	}
+
+	if err := waitForDashboardReady(ctx, srv.URL, time.Second); err != nil {
+		t.Fatalf("waitForDashboardReady: %s", err.Error())
+	}
+
+	if err := waitForSelector(ctx, srv.URL, ".widget-ready", time.Second); err != nil {
+		t.Fatalf("waitForSelector did not see the lazy-loaded widget: %s", err.Error())
+	}
+
+	if err := waitForSelector(ctx, srv.URL, ".never-appears", 200*time.Millisecond); err == nil {
+		t.Fatal("waitForSelector should time out for a selector that never appears")
+	}
+}
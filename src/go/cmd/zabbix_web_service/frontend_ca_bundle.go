@@ -0,0 +1,140 @@
+/*
+** Copyright (C) 2001-2024 Zabbix SIA
+**
+** This program is free software: you can redistribute it and/or modify it under the terms of
+** the GNU Affero General Public License as published by the Free Software Foundation, version 3.
+**
+** This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+** without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+** See the GNU Affero General Public License for more details.
+**
+** You should have received a copy of the GNU Affero General Public License along with this program.
+** If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+// defaultFrontendTLSPort is used when the frontend URL does not specify an explicit port.
+const defaultFrontendTLSPort = "443"
+
+// frontendDialTimeout bounds how long verifyFrontendCertificate waits to connect to the
+// frontend, so an unreachable or slow-handshaking host cannot hold a report request open
+// indefinitely.
+const frontendDialTimeout = 10 * time.Second
+
+// frontendCABundleSeparator separates the PEM file paths in options.FrontendCABundle.
+const frontendCABundleSeparator = ","
+
+var (
+	frontendCAPoolMu sync.Mutex
+	frontendCAPool   *x509.CertPool
+)
+
+// frontendCABundlePaths splits options.FrontendCABundle into individual PEM file paths.
+func frontendCABundlePaths() []string {
+	var paths []string
+
+	for _, path := range strings.Split(options.FrontendCABundle, frontendCABundleSeparator) {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// loadFrontendCABundle reads and parses options.FrontendCABundle, caching the resulting pool.
+// A failed attempt is not cached, so a transient error does not wedge the process.
+func loadFrontendCABundle() (*x509.CertPool, error) {
+	frontendCAPoolMu.Lock()
+	defer frontendCAPoolMu.Unlock()
+
+	if frontendCAPool != nil {
+		return frontendCAPool, nil
+	}
+
+	pool := x509.NewCertPool()
+
+	for _, path := range frontendCABundlePaths() {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errs.Wrapf(err, "cannot read frontend CA bundle file '%s'", path)
+		}
+
+		if ok := pool.AppendCertsFromPEM(pem); !ok {
+			return nil, errs.Errorf("no certificates found in frontend CA bundle file '%s'", path)
+		}
+	}
+
+	frontendCAPool = pool
+
+	return frontendCAPool, nil
+}
+
+// frontendDialAddress returns the host:port to dial for u, defaulting the port to
+// defaultFrontendTLSPort when u does not specify one.
+func frontendDialAddress(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = defaultFrontendTLSPort
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// verifyFrontendCertificate dials u and checks whether the certificate chain it presents
+// verifies against pool, without relying on the system trust store. The dial and handshake
+// are bounded by frontendDialTimeout and abandoned early if ctx is done.
+func verifyFrontendCertificate(ctx context.Context, pool *x509.CertPool, u *url.URL) error {
+	addr := frontendDialAddress(u)
+
+	ctx, cancel := context.WithTimeout(ctx, frontendDialTimeout)
+	defer cancel()
+
+	rawConn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return errs.Wrapf(err, "cannot establish TCP connection to '%s'", addr)
+	}
+	defer rawConn.Close()
+
+	conn := tls.Client(rawConn, &tls.Config{InsecureSkipVerify: true}) //nolint:gosec
+
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return errs.Wrapf(err, "cannot establish TLS connection to '%s'", addr)
+	}
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return errs.Errorf("no certificate presented by '%s'", addr)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		DNSName:       u.Hostname(),
+	})
+	if err != nil {
+		return errs.Wrapf(err, "certificate for '%s' does not chain to the configured CA bundle", addr)
+	}
+
+	return nil
+}
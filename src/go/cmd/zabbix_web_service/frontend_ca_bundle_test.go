@@ -0,0 +1,162 @@
+/*
+** Copyright (C) 2001-2024 Zabbix SIA
+**
+** This program is free software: you can redistribute it and/or modify it under the terms of
+** the GNU Affero General Public License as published by the Free Software Foundation, version 3.
+**
+** This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+** without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+** See the GNU Affero General Public License for more details.
+**
+** You should have received a copy of the GNU Affero General Public License along with this program.
+** If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFrontendCABundlePaths(t *testing.T) {
+	cases := []struct {
+		name   string
+		bundle string
+		want   []string
+	}{
+		{"empty", "", nil},
+		{"single", "/etc/ca1.pem", []string{"/etc/ca1.pem"}},
+		{"multiple trimmed", "/etc/ca1.pem, /etc/ca2.pem ,/etc/ca3.pem", []string{"/etc/ca1.pem", "/etc/ca2.pem", "/etc/ca3.pem"}},
+		{"drops empty entries", "/etc/ca1.pem,,  ,/etc/ca2.pem", []string{"/etc/ca1.pem", "/etc/ca2.pem"}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			options.FrontendCABundle = tt.bundle
+
+			got := frontendCABundlePaths()
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("frontendCABundlePaths() = %v, want %v", got, tt.want)
+			}
+
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("frontendCABundlePaths()[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFrontendDialAddress(t *testing.T) {
+	cases := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"no port defaults to 443", "https://frontend.example/zabbix.php", "frontend.example:443"},
+		{"explicit port is kept", "https://frontend.example:8443/zabbix.php", "frontend.example:8443"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatalf("url.Parse(%q): %s", tt.url, err.Error())
+			}
+
+			if got := frontendDialAddress(u); got != tt.want {
+				t.Errorf("frontendDialAddress(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyFrontendCertificate(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %s", srv.URL, err.Error())
+	}
+
+	trustedPool := x509.NewCertPool()
+	trustedPool.AddCert(srv.Certificate())
+
+	if err := verifyFrontendCertificate(context.Background(), trustedPool, u); err != nil {
+		t.Errorf("verifyFrontendCertificate() with the server's own cert in the pool: %s", err.Error())
+	}
+
+	untrustedPool := x509.NewCertPool()
+
+	if err := verifyFrontendCertificate(context.Background(), untrustedPool, u); err == nil {
+		t.Error("verifyFrontendCertificate() with an empty pool should fail to chain the certificate")
+	}
+}
+
+func TestVerifyFrontendCertificateRespectsContext(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %s", srv.URL, err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := verifyFrontendCertificate(ctx, x509.NewCertPool(), u); err == nil {
+		t.Error("verifyFrontendCertificate() with an already-cancelled context should fail instead of dialing")
+	}
+}
+
+func TestLoadFrontendCABundle(t *testing.T) {
+	frontendCAPoolMu.Lock()
+	frontendCAPool = nil
+	frontendCAPoolMu.Unlock()
+
+	t.Cleanup(func() {
+		frontendCAPoolMu.Lock()
+		frontendCAPool = nil
+		frontendCAPoolMu.Unlock()
+	})
+
+	missing := filepath.Join(t.TempDir(), "does-not-exist.pem")
+	options.FrontendCABundle = missing
+
+	if _, err := loadFrontendCABundle(); err == nil {
+		t.Fatal("loadFrontendCABundle() with a missing file should fail")
+	}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	bundlePath := filepath.Join(t.TempDir(), "ca.pem")
+
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(bundlePath, pemBytes, 0o600); err != nil {
+		t.Fatalf("os.WriteFile(%q): %s", bundlePath, err.Error())
+	}
+
+	options.FrontendCABundle = bundlePath
+
+	pool, err := loadFrontendCABundle()
+	if err != nil {
+		t.Fatalf("loadFrontendCABundle() after a failed attempt should retry instead of staying broken: %s", err.Error())
+	}
+
+	if pool == nil {
+		t.Fatal("loadFrontendCABundle() returned a nil pool")
+	}
+}
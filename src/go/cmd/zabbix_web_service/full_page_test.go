@@ -0,0 +1,40 @@
+/*
+** Copyright (C) 2001-2024 Zabbix SIA
+**
+** This program is free software: you can redistribute it and/or modify it under the terms of
+** the GNU Affero General Public License as published by the Free Software Foundation, version 3.
+**
+** This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+** without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+** See the GNU Affero General Public License for more details.
+**
+** You should have received a copy of the GNU Affero General Public License along with this program.
+** If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import "testing"
+
+func TestCapReportHeight(t *testing.T) {
+	cases := []struct {
+		name          string
+		scrollHeight  float64
+		maxConfigured int
+		want          int64
+	}{
+		{"under configured max", 5000, 10000, 5000},
+		{"at configured max", 10000, 10000, 10000},
+		{"over configured max is capped", 50000, 10000, 10000},
+		{"unset max falls back to defaultMaxReportHeightPx", 50000, 0, defaultMaxReportHeightPx},
+		{"negative configured max falls back to default", 50000, -1, defaultMaxReportHeightPx},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := capReportHeight(tt.scrollHeight, tt.maxConfigured); got != tt.want {
+				t.Errorf("capReportHeight(%v, %d) = %d, want %d", tt.scrollHeight, tt.maxConfigured, got, tt.want)
+			}
+		})
+	}
+}
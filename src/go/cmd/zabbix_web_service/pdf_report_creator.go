@@ -27,9 +27,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/chromedp/cdproto/cdp"
 	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/fetch"
 	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/security"
 	"github.com/chromedp/chromedp"
 	"golang.zabbix.com/sdk/errs"
 	"golang.zabbix.com/sdk/log"
@@ -38,6 +41,41 @@ import (
 
 const netErrCertAuthorityInvalid = "net::ERR_CERT_AUTHORITY_INVALID"
 
+// Supported values of parameters["format"].
+const (
+	reportFormatPDF  = "pdf"
+	reportFormatPNG  = "png"
+	reportFormatJPEG = "jpeg"
+)
+
+// defaultScreenshotQuality is used for jpeg/png output when parameters["quality"] is not set.
+const defaultScreenshotQuality = 100
+
+// defaultReadyTimeout is the default value of parameters["ready_timeout_ms"].
+const defaultReadyTimeout = 45 * time.Second
+
+// maxReadyTimeout bounds parameters["ready_timeout_ms"].
+const maxReadyTimeout = 45 * time.Second
+
+// maxSettleDelay bounds parameters["settle_ms"].
+const maxSettleDelay = 30 * time.Second
+
+// waitSelectorsSeparator separates the entries of parameters["wait_selectors"].
+const waitSelectorsSeparator = ","
+
+// maxWaitSelectors bounds how many entries parameters["wait_selectors"] may contain.
+const maxWaitSelectors = 10
+
+// defaultFullPageInitialHeight is the initial viewport height for a height=0 request. A
+// parameters["fullPage"] request with a non-zero height starts at that height instead.
+const defaultFullPageInitialHeight = 1080
+
+// defaultMaxReportHeightPx is used when options.MaxReportHeightPx is unset.
+const defaultMaxReportHeightPx = 20000
+
+// fullPageLayoutSettle is how long to let the page reflow after resizing to its full height.
+const fullPageLayoutSettle = 200 * time.Millisecond
+
 type requestBody struct {
 	URL        string            `json:"url"`
 	Header     map[string]string `json:"headers"`
@@ -52,9 +90,30 @@ type reportSize struct {
 
 // PDF report generation request parameters.
 type reportReqParams struct {
-	cookieParams []*network.CookieParam
-	size         reportSize
-	url          string
+	cookieParams  []*network.CookieParam
+	size          reportSize
+	url           string
+	format        string
+	quality       int64
+	settleDelay   time.Duration
+	waitSelectors []string
+	readyTimeout  time.Duration
+	trustCert     bool
+	fullPage      bool
+	authToken     string
+	authTokenHost string
+}
+
+// contentType returns the HTTP Content-Type that corresponds to the requested report format.
+func (r reportReqParams) contentType() string {
+	switch r.format {
+	case reportFormatPNG:
+		return "image/png"
+	case reportFormatJPEG:
+		return "image/jpeg"
+	default:
+		return "application/pdf"
+	}
 }
 
 // Report generation request parameters.
@@ -75,6 +134,15 @@ func (b *requestBody) httpCookiesGet() []*http.Cookie {
 	return r.Cookies()
 }
 
+// authToken returns the Zabbix API token to authenticate to the frontend with.
+func (b *requestBody) authToken() string {
+	if auth := b.Header["Authorization"]; strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	return b.Parameters["auth_token"]
+}
+
 func logAndWriteError(w http.ResponseWriter, errMsg string, code int) {
 	log.Errf("%s", errMsg)
 	w.Header().Set("Content-Type", "application/problem+json")
@@ -131,28 +199,63 @@ func (h *handler) report(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	opts := chromedp.DefaultExecAllocatorOptions[:]
+	width, err := strconv.ParseInt(req.Parameters["width"], 10, 64)
+	if err != nil {
+		logAndWriteError(w, fmt.Sprintf("Incorrect parameter width: %s", err.Error()), http.StatusBadRequest)
 
-	if options.IgnoreURLCertErrors == 1 {
-		opts = append(opts, chromedp.Flag("ignore-certificate-errors", "1"))
+		return
 	}
 
-	allocCtx, cancel := chromedp.NewExecAllocator(r.Context(), opts...)
-	defer cancel()
+	height, err := strconv.ParseInt(req.Parameters["height"], 10, 64)
+	if err != nil {
+		logAndWriteError(w, fmt.Sprintf("Incorrect parameter height: %s", err.Error()), http.StatusBadRequest)
 
-	ctx, cancel := chromedp.NewContext(allocCtx)
-	defer cancel()
+		return
+	}
 
-	width, err := strconv.ParseInt(req.Parameters["width"], 10, 64)
+	fullPage := height == 0 || req.Parameters["fullPage"] == "true"
+
+	if height == 0 {
+		height = defaultFullPageInitialHeight
+	}
+
+	format, err := parseReportFormat(req.Parameters["format"])
 	if err != nil {
-		logAndWriteError(w, fmt.Sprintf("Incorrect parameter width: %s", err.Error()), http.StatusBadRequest)
+		logAndWriteError(w, fmt.Sprintf("Incorrect parameter format: %s", err.Error()), http.StatusBadRequest)
 
 		return
 	}
 
-	height, err := strconv.ParseInt(req.Parameters["height"], 10, 64)
+	quality, err := parseQualityParam(req.Parameters["quality"])
 	if err != nil {
-		logAndWriteError(w, fmt.Sprintf("Incorrect parameter height: %s", err.Error()), http.StatusBadRequest)
+		logAndWriteError(w, fmt.Sprintf("Incorrect parameter quality: %s", err.Error()), http.StatusBadRequest)
+
+		return
+	}
+
+	settleDelay, err := parseDurationMsParam(req.Parameters, "settle_ms", maxSettleDelay, 0)
+	if err != nil {
+		logAndWriteError(w, fmt.Sprintf("Incorrect parameter settle_ms: %s", err.Error()), http.StatusBadRequest)
+
+		return
+	}
+
+	readyTimeout, err := parseDurationMsParam(req.Parameters, "ready_timeout_ms", maxReadyTimeout, defaultReadyTimeout)
+	if err != nil {
+		logAndWriteError(w, fmt.Sprintf("Incorrect parameter ready_timeout_ms: %s", err.Error()), http.StatusBadRequest)
+
+		return
+	}
+
+	waitSelectors := parseWaitSelectors(req.Parameters["wait_selectors"])
+
+	if len(waitSelectors) > maxWaitSelectors {
+		logAndWriteError(
+			w,
+			fmt.Sprintf("Incorrect parameter wait_selectors: at most %d selectors are allowed, got %d.",
+				maxWaitSelectors, len(waitSelectors)),
+			http.StatusBadRequest,
+		)
 
 		return
 	}
@@ -184,9 +287,53 @@ func (h *handler) report(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var trustCert bool
+
+	if u.Scheme == "https" && options.FrontendCABundle != "" {
+		pool, err := loadFrontendCABundle()
+		if err != nil {
+			logAndWriteError(w, fmt.Sprintf("Cannot load frontend CA bundle: %s", err.Error()), http.StatusInternalServerError)
+
+			return
+		}
+
+		if err := verifyFrontendCertificate(r.Context(), pool, u); err != nil {
+			if options.IgnoreURLCertErrors != 1 {
+				logAndWriteError(
+					w,
+					fmt.Sprintf("Frontend certificate is not in the configured CA bundle: %s", err.Error()),
+					http.StatusBadGateway,
+				)
+
+				return
+			}
+
+			log.Warningf(
+				"frontend certificate for '%s' is not in the configured CA bundle (%s), falling back to "+
+					"IgnoreURLCertErrors", u.Host, err.Error())
+		} else {
+			trustCert = true
+		}
+	}
+
+	// Acquired only after the frontend certificate has been verified, so a slow or unreachable
+	// frontend host cannot tie up a pooled browser worker while verifyFrontendCertificate dials it.
+	worker, err := h.pool.acquire(r.Context())
+	if err != nil {
+		logAndWriteError(w, fmt.Sprintf("Cannot acquire browser worker: %s", err.Error()), http.StatusInternalServerError)
+
+		return
+	}
+	defer h.pool.release(worker)
+
+	ctx, cancel := chromedp.NewContext(worker.ctx)
+	defer cancel()
+
+	// Never log the resolved token itself, only whether one was supplied.
 	log.Tracef(
-		"making chrome headless request with parameters url: %s, width: %s, height: %s for report request from %s",
-		u.String(), req.Parameters["width"], req.Parameters["height"], r.RemoteAddr)
+		"making chrome headless request with parameters url: %s, width: %s, height: %s, auth_token present: %t "+
+			"for report request from %s",
+		u.String(), req.Parameters["width"], req.Parameters["height"], req.authToken() != "", r.RemoteAddr)
 
 	var cookieParams []*network.CookieParam
 
@@ -209,7 +356,16 @@ func (h *handler) report(w http.ResponseWriter, r *http.Request) {
 			height: height,
 			width:  width,
 		},
-		url: u.String(),
+		url:           u.String(),
+		format:        format,
+		quality:       quality,
+		settleDelay:   settleDelay,
+		waitSelectors: waitSelectors,
+		readyTimeout:  readyTimeout,
+		trustCert:     trustCert,
+		fullPage:      fullPage,
+		authToken:     req.authToken(),
+		authTokenHost: u.Host,
 	}
 
 	respChan := make(chan chromedpResp)
@@ -232,7 +388,7 @@ func (h *handler) report(w http.ResponseWriter, r *http.Request) {
 
 	log.Infof("writing response to report request from %s", r.RemoteAddr)
 
-	w.Header().Set("Content-type", "application/pdf")
+	w.Header().Set("Content-type", cdpReqParams.contentType())
 
 	_, err = w.Write(resp.data)
 	if err != nil {
@@ -265,24 +421,41 @@ func runCDP(
 		},
 	)
 
-	err := chromedp.Run(ctx, chromedp.Tasks{
+	tasks := chromedp.Tasks{
 		network.SetCookies(req.cookieParams),
 		emulation.SetDeviceMetricsOverride(req.size.width, req.size.height, 1, false),
-		prepareDashboard(req.url),
+	}
+
+	if req.authToken != "" {
+		// Scoped to req.authTokenHost via the Fetch domain so the token is only ever attached to
+		// requests aimed at the frontend, not to every cross-origin resource the dashboard loads.
+		tasks = append(tasks, injectAuthToken(req.authTokenHost, req.authToken))
+	}
+
+	if req.trustCert {
+		tasks = append(tasks, trustCertificateErrors())
+	}
+
+	resolvedReq := req
+
+	tasks = append(tasks, prepareDashboard(req))
+
+	if req.fullPage {
+		tasks = append(tasks, resizeToFullPage(&resolvedReq))
+	}
+
+	tasks = append(tasks,
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			timeoutContext, cancel := context.WithTimeout(ctx, time.Duration(options.Timeout)*time.Second)
 			defer cancel()
 			var err error
-			out, _, err = page.PrintToPDF().
-				WithPrintBackground(true).
-				WithPreferCSSPageSize(true).
-				WithPaperWidth(pixels2inches(req.size.width)).
-				WithPaperHeight(pixels2inches(req.size.height)).
-				Do(timeoutContext)
+			out, err = captureReport(timeoutContext, resolvedReq)
 
 			return err
 		}),
-	})
+	)
+
+	err := chromedp.Run(ctx, tasks)
 
 	if listenerErr != nil {
 		// error is logged since in case of listenerErr chromedp error might be nil or some other error,
@@ -302,22 +475,235 @@ func runCDP(
 	resp <- chromedpResp{data: out}
 }
 
+// injectAuthToken adds an "Authorization: Bearer <token>" header to requests aimed at host.
+func injectAuthToken(host, token string) chromedp.ActionFunc {
+	return func(ctx context.Context) error {
+		lctx, target := ctx, chromedp.FromContext(ctx).Target
+
+		chromedp.ListenTarget(lctx, func(ev any) {
+			paused, ok := ev.(*fetch.EventRequestPaused)
+			if !ok {
+				return
+			}
+
+			go func() {
+				headers := make([]*fetch.HeaderEntry, 0, len(paused.Request.Headers)+1)
+				for name, value := range paused.Request.Headers {
+					headers = append(headers, &fetch.HeaderEntry{Name: name, Value: fmt.Sprint(value)})
+				}
+
+				headers = append(headers, &fetch.HeaderEntry{Name: "Authorization", Value: "Bearer " + token})
+
+				execCtx := cdp.WithExecutor(lctx, target)
+
+				err := fetch.ContinueRequest(paused.RequestID).WithHeaders(headers).Do(execCtx)
+				if err != nil {
+					log.Errf("failed to continue intercepted request while injecting auth token: %s", err.Error())
+				}
+			}()
+		})
+
+		return fetch.Enable().WithPatterns([]*fetch.RequestPattern{
+			{URLPattern: "*://" + host + "/*"},
+		}).Do(ctx)
+	}
+}
+
+// trustCertificateErrors ignores certificate errors for the rest of the tab's page load.
+//
+// cdproto's Security domain has no per-host variant of this switch (the EventCertificateError /
+// HandleCertificateError pair some cdproto versions once exposed for that purpose is gone from
+// the version this repo builds against), so this is target-wide: once the frontend's certificate
+// has verified against options.FrontendCABundle, any other origin the dashboard happens to pull a
+// resource from also has its certificate errors ignored for this request.
+func trustCertificateErrors() chromedp.ActionFunc {
+	return func(ctx context.Context) error {
+		return security.SetIgnoreCertificateErrors(true).Do(ctx)
+	}
+}
+
+// resizeToFullPage resizes the viewport to the page's full scroll height, capped by capReportHeight.
+// req is updated in place.
+func resizeToFullPage(req *reportReqParams) chromedp.ActionFunc {
+	return func(ctx context.Context) error {
+		var scrollHeight float64
+
+		err := chromedp.Evaluate(`document.documentElement.scrollHeight`, &scrollHeight).Do(ctx)
+		if err != nil {
+			return errs.Wrap(err, "cannot measure full page height")
+		}
+
+		req.size.height = capReportHeight(scrollHeight, options.MaxReportHeightPx)
+
+		err = emulation.SetDeviceMetricsOverride(req.size.width, req.size.height, 1, false).Do(ctx)
+		if err != nil {
+			return errs.Wrap(err, "cannot resize page to full height")
+		}
+
+		return chromedp.Sleep(fullPageLayoutSettle).Do(ctx)
+	}
+}
+
+// capReportHeight caps scrollHeight at maxConfigured, or defaultMaxReportHeightPx when unset.
+func capReportHeight(scrollHeight float64, maxConfigured int) int64 {
+	maxHeight := int64(maxConfigured)
+	if maxHeight <= 0 {
+		maxHeight = defaultMaxReportHeightPx
+	}
+
+	height := int64(scrollHeight)
+	if height > maxHeight {
+		height = maxHeight
+	}
+
+	return height
+}
+
+// captureReport renders the dashboard as a PDF or, for raster formats, as a PNG/JPEG screenshot.
+func captureReport(ctx context.Context, req reportReqParams) ([]byte, error) {
+	switch req.format {
+	case reportFormatPNG, reportFormatJPEG:
+		screenshotFormat := page.CaptureScreenshotFormatPng
+		if req.format == reportFormatJPEG {
+			screenshotFormat = page.CaptureScreenshotFormatJpeg
+		}
+
+		out, err := page.CaptureScreenshot().
+			WithFormat(screenshotFormat).
+			WithQuality(req.quality).
+			WithClip(&page.Viewport{
+				X:      0,
+				Y:      0,
+				Width:  float64(req.size.width),
+				Height: float64(req.size.height),
+				Scale:  1,
+			}).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return out, nil
+	default:
+		out, _, err := page.PrintToPDF().
+			WithPrintBackground(true).
+			WithPreferCSSPageSize(true).
+			WithPaperWidth(pixels2inches(req.size.width)).
+			WithPaperHeight(pixels2inches(req.size.height)).
+			Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return out, nil
+	}
+}
+
 func pixels2inches(value int64) float64 {
 	return float64(value) * 0.0104166667
 }
 
-func prepareDashboard(url string) chromedp.ActionFunc {
+// parseReportFormat validates parameters["format"], defaulting to PDF when unset.
+func parseReportFormat(format string) (string, error) {
+	switch format {
+	case "":
+		return reportFormatPDF, nil
+	case reportFormatPDF, reportFormatPNG, reportFormatJPEG:
+		return format, nil
+	default:
+		return "", errs.Errorf("unknown format: \"%s\"", format)
+	}
+}
+
+// parseQualityParam validates parameters["quality"], defaulting to defaultScreenshotQuality.
+func parseQualityParam(quality string) (int64, error) {
+	if quality == "" {
+		return defaultScreenshotQuality, nil
+	}
+
+	q, err := strconv.ParseInt(quality, 10, 64)
+	if err != nil || q < 0 || q > 100 {
+		return 0, errs.New("must be an integer between 0 and 100")
+	}
+
+	return q, nil
+}
+
+// parseWaitSelectors splits parameters["wait_selectors"] on waitSelectorsSeparator.
+func parseWaitSelectors(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+
+	var selectors []string
+
+	for _, selector := range strings.Split(raw, waitSelectorsSeparator) {
+		if selector = strings.TrimSpace(selector); selector != "" {
+			selectors = append(selectors, selector)
+		}
+	}
+
+	return selectors
+}
+
+// parseDurationMsParam reads a millisecond duration from parameters[key], falling back to def.
+func parseDurationMsParam(params map[string]string, key string, max, def time.Duration) (time.Duration, error) {
+	raw, ok := params[key]
+	if !ok || raw == "" {
+		return def, nil
+	}
+
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, errs.Errorf("must be an integer number of milliseconds: %s", err.Error())
+	}
+
+	d := time.Duration(ms) * time.Millisecond
+
+	if d < 0 || d > max {
+		return 0, errs.Errorf("must be between 0 and %d ms", max.Milliseconds())
+	}
+
+	return d, nil
+}
+
+func prepareDashboard(req reportReqParams) chromedp.ActionFunc {
 	return func(ctx context.Context) error {
-		_, _, _, err := page.Navigate(url).Do(ctx)
+		_, _, _, err := page.Navigate(req.url).Do(ctx)
 		if err != nil {
 			return err
 		}
 
-		return waitForDashboardReady(ctx, url)
+		if err := waitForDashboardReady(ctx, req.url, req.readyTimeout); err != nil {
+			return err
+		}
+
+		if len(req.waitSelectors) > 0 {
+			// All wait_selectors share one req.readyTimeout deadline so a request with many
+			// selectors cannot hold a pooled browser worker for selectors-count x readyTimeout.
+			selectorsCtx, cancel := context.WithTimeout(ctx, req.readyTimeout)
+			defer cancel()
+
+			for _, selector := range req.waitSelectors {
+				if err := waitForSelector(selectorsCtx, req.url, selector, req.readyTimeout); err != nil {
+					return err
+				}
+			}
+		}
+
+		if req.settleDelay > 0 {
+			select {
+			case <-time.After(req.settleDelay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		return nil
 	}
 }
 
-func waitForDashboardReady(ctx context.Context, url string) error {
+func waitForDashboardReady(ctx context.Context, url string, timeout time.Duration) error {
 	var isReady bool
 
 	err := chromedp.Run(
@@ -325,7 +711,7 @@ func waitForDashboardReady(ctx context.Context, url string) error {
 		chromedp.Poll(
 			"document.querySelector('.wrapper.is-ready') !== null",
 			&isReady,
-			chromedp.WithPollingTimeout(time.Second*45),
+			chromedp.WithPollingTimeout(timeout),
 		),
 	)
 	if err != nil {
@@ -341,6 +727,29 @@ func waitForDashboardReady(ctx context.Context, url string) error {
 	return nil
 }
 
+// waitForSelector polls until the given CSS selector is present in the DOM.
+func waitForSelector(ctx context.Context, url string, selector string, timeout time.Duration) error {
+	var found bool
+
+	err := chromedp.Run(
+		ctx,
+		chromedp.Poll(
+			fmt.Sprintf("document.querySelector(%q) !== null", selector),
+			&found,
+			chromedp.WithPollingTimeout(timeout),
+		),
+	)
+	if err != nil {
+		return errs.Wrapf(err, "wait selector '%s' did not appear, url: '%s'", selector, url)
+	}
+
+	if !found {
+		return errs.Errorf("wait selector '%s' did not appear with no error, url: '%s'", selector, url)
+	}
+
+	return nil
+}
+
 func parseUrl(u string) (*url.URL, error) {
 	if u == "" {
 		return nil, errors.New("url is empty")
@@ -377,4 +786,4 @@ func handleErr(errStr string) error {
 			errStr,
 		)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,99 @@
+/*
+** Copyright (C) 2001-2024 Zabbix SIA
+**
+** This program is free software: you can redistribute it and/or modify it under the terms of
+** the GNU Affero General Public License as published by the Free Software Foundation, version 3.
+**
+** This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+** without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+** See the GNU Affero General Public License for more details.
+**
+** You should have received a copy of the GNU Affero General Public License along with this program.
+** If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import "testing"
+
+func TestParseReportFormat(t *testing.T) {
+	cases := []struct {
+		name    string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{"empty defaults to pdf", "", reportFormatPDF, false},
+		{"pdf", reportFormatPDF, reportFormatPDF, false},
+		{"png", reportFormatPNG, reportFormatPNG, false},
+		{"jpeg", reportFormatJPEG, reportFormatJPEG, false},
+		{"unknown format", "gif", "", true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReportFormat(tt.format)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseReportFormat(%q) error = %v, wantErr %t", tt.format, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("parseReportFormat(%q) = %q, want %q", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentType(t *testing.T) {
+	cases := []struct {
+		format string
+		want   string
+	}{
+		{reportFormatPDF, "application/pdf"},
+		{reportFormatPNG, "image/png"},
+		{reportFormatJPEG, "image/jpeg"},
+		{"", "application/pdf"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.format, func(t *testing.T) {
+			r := reportReqParams{format: tt.format}
+
+			if got := r.contentType(); got != tt.want {
+				t.Errorf("contentType() for format %q = %q, want %q", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQualityParam(t *testing.T) {
+	cases := []struct {
+		name    string
+		quality string
+		want    int64
+		wantErr bool
+	}{
+		{"empty defaults to defaultScreenshotQuality", "", defaultScreenshotQuality, false},
+		{"valid value", "50", 50, false},
+		{"lower bound", "0", 0, false},
+		{"upper bound", "100", 100, false},
+		{"negative", "-1", 0, true},
+		{"above 100", "101", 0, true},
+		{"not an integer", "high", 0, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseQualityParam(tt.quality)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseQualityParam(%q) error = %v, wantErr %t", tt.quality, err, tt.wantErr)
+			}
+
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseQualityParam(%q) = %d, want %d", tt.quality, got, tt.want)
+			}
+		})
+	}
+}